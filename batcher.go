@@ -14,6 +14,27 @@ type Batcher interface {
 	Batch() []int
 }
 
+var (
+	_ Batcher = &RandomBatch{}
+	_ Batcher = &EpochBatch{}
+)
+
+// BatchSizer is an optional interface a Batcher may implement to report its
+// target minibatch size. SGD uses it to translate Settings.Epochs into an
+// equivalent iteration count.
+type BatchSizer interface {
+	BatchSize() int
+}
+
+// EpochBatcher is an optional interface a Batcher may implement when it
+// organizes its indices into epochs, complete passes over the dataset.
+type EpochBatcher interface {
+	Batcher
+	// Epoch returns the number of the epoch currently in progress, starting
+	// at 0.
+	Epoch() int
+}
+
 // RandomBatch generates a minibatch of the specified size at random from the
 // total dataset.
 type RandomBatch struct {
@@ -49,3 +70,70 @@ func (r *RandomBatch) Batch() []int {
 	}
 	return r.idxs
 }
+
+// BatchSize returns the minibatch size, satisfying BatchSizer.
+func (r *RandomBatch) BatchSize() int {
+	return r.Size
+}
+
+// EpochBatch generates minibatches by walking a shuffled permutation of the
+// dataset in contiguous chunks of Size. When the permutation is exhausted, it
+// is reshuffled and a new epoch begins, so every index is visited exactly
+// once per epoch.
+type EpochBatch struct {
+	// Size is the minibatch size.
+	Size int
+	// Source sets the random number source.
+	Source rand.Source
+
+	n     int
+	perm  []int
+	pos   int
+	epoch int
+}
+
+func (e *EpochBatch) Init(nSamples int) {
+	e.n = nSamples
+	e.perm = make([]int, nSamples)
+	e.pos = nSamples
+	e.epoch = -1
+}
+
+func (e *EpochBatch) Batch() []int {
+	if e.pos >= e.n {
+		e.reshuffle()
+	}
+	end := e.pos + e.Size
+	if end > e.n {
+		end = e.n
+	}
+	batch := e.perm[e.pos:end]
+	e.pos = end
+	return batch
+}
+
+func (e *EpochBatch) reshuffle() {
+	for i := range e.perm {
+		e.perm[i] = i
+	}
+	shuffle := rand.Shuffle
+	if e.Source != nil {
+		shuffle = rand.New(e.Source).Shuffle
+	}
+	shuffle(e.n, func(i, j int) {
+		e.perm[i], e.perm[j] = e.perm[j], e.perm[i]
+	})
+	e.pos = 0
+	e.epoch++
+}
+
+// Epoch returns the number of the epoch currently in progress, satisfying
+// EpochBatcher.
+func (e *EpochBatch) Epoch() int {
+	return e.epoch
+}
+
+// BatchSize returns the minibatch size, satisfying BatchSizer.
+func (e *EpochBatch) BatchSize() int {
+	return e.Size
+}
@@ -0,0 +1,160 @@
+package sgd
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// LRSchedule computes a learning rate as a function of iteration count,
+// decoupled from any particular Stepper. Use it together with Scheduled to
+// apply a schedule to a Stepper that otherwise has no notion of time-varying
+// rates.
+type LRSchedule interface {
+	// Rate returns the learning rate for the given iteration, starting at 0.
+	Rate(iter int) float64
+}
+
+var (
+	_ LRSchedule = ConstantLR{}
+	_ LRSchedule = InverseTimeLR{}
+	_ LRSchedule = ExponentialLR{}
+	_ LRSchedule = StepLR{}
+	_ LRSchedule = CosineAnnealingLR{}
+	_ LRSchedule = WarmupLinear{}
+)
+
+// ConstantLR is an LRSchedule that returns the same rate at every iteration.
+type ConstantLR struct {
+	Value float64
+}
+
+func (c ConstantLR) Rate(iter int) float64 {
+	return c.Value
+}
+
+// InverseTimeLR is an LRSchedule that decays as a/(b+t), matching the decay
+// used internally by Anneal and Momentum.
+type InverseTimeLR struct {
+	A float64
+	B float64
+}
+
+func (s InverseTimeLR) Rate(iter int) float64 {
+	return s.A / (s.B + float64(iter))
+}
+
+// ExponentialLR is an LRSchedule that decays geometrically:
+//  Rate(t) = Initial * Gamma^t
+type ExponentialLR struct {
+	Initial float64
+	Gamma   float64
+}
+
+func (s ExponentialLR) Rate(iter int) float64 {
+	return s.Initial * math.Pow(s.Gamma, float64(iter))
+}
+
+// StepLR is an LRSchedule that decays by a factor of Gamma every StepSize
+// iterations:
+//  Rate(t) = Initial * Gamma^floor(t/StepSize)
+type StepLR struct {
+	Initial  float64
+	StepSize int
+	Gamma    float64
+}
+
+func (s StepLR) Rate(iter int) float64 {
+	return s.Initial * math.Pow(s.Gamma, math.Floor(float64(iter)/float64(s.StepSize)))
+}
+
+// CosineAnnealingLR is an LRSchedule that anneals the rate between Max and
+// Min following a cosine curve over Period iterations, then repeats:
+//  Rate(t) = Min + (Max-Min)/2 * (1 + cos(π*(t mod Period)/Period))
+type CosineAnnealingLR struct {
+	Max    float64
+	Min    float64
+	Period float64
+}
+
+func (s CosineAnnealingLR) Rate(iter int) float64 {
+	t := math.Mod(float64(iter), s.Period)
+	return s.Min + (s.Max-s.Min)/2*(1+math.Cos(math.Pi*t/s.Period))
+}
+
+// WarmupLinear is an LRSchedule that ramps the rate linearly from 0 to Base
+// over the first Warmup iterations, then holds at Base.
+type WarmupLinear struct {
+	Warmup int
+	Base   float64
+}
+
+func (s WarmupLinear) Rate(iter int) float64 {
+	if iter >= s.Warmup {
+		return s.Base
+	}
+	return s.Base * float64(iter) / float64(s.Warmup)
+}
+
+// Scheduled wraps a Stepper with an LRSchedule, so steppers with no native
+// notion of a time-varying rate (or a fixed schedule baked in, like Anneal)
+// can be driven by any LRSchedule. If Base implements RateSetter, Scheduled
+// calls SetRate with the schedule's rate directly; otherwise it rescales the
+// step produced by Base by Schedule.Rate(t)/Schedule.Rate(t0), where t0 is
+// the first iteration at which the schedule gives a non-zero rate (usually
+// 0, but later for schedules like WarmupLinear that start at 0); iterations
+// before t0 produce a zero step, since the schedule calls for no movement
+// yet.
+//
+// Scheduled implements IterAware, so SGD keeps it informed of the current
+// iteration automatically.
+type Scheduled struct {
+	Base     Stepper
+	Schedule LRSchedule
+
+	rateSetter   RateSetter
+	baseRate     float64
+	haveBaseRate bool
+	iter         int
+}
+
+var _ IterAware = &Scheduled{}
+
+func (s *Scheduled) Init(dim int) {
+	s.Base.Init(dim)
+	s.rateSetter, _ = s.Base.(RateSetter)
+	s.baseRate = s.Schedule.Rate(0)
+	s.haveBaseRate = s.baseRate != 0
+	s.iter = 0
+}
+
+// NotifyIter records the current iteration, satisfying IterAware.
+func (s *Scheduled) NotifyIter(iter int) {
+	s.iter = iter
+}
+
+func (s *Scheduled) Step(step, grad []float64) {
+	rate := s.Schedule.Rate(s.iter)
+	if s.rateSetter != nil {
+		s.rateSetter.SetRate(rate)
+		s.Base.Step(step, grad)
+		return
+	}
+	s.Base.Step(step, grad)
+	switch {
+	case rate == 0:
+		// The schedule calls for no movement yet (e.g. the ramp-up
+		// iterations of WarmupLinear); zero the step directly rather than
+		// dividing by a zero baseRate.
+		for i := range step {
+			step[i] = 0
+		}
+	case !s.haveBaseRate:
+		// Rate(0) was degenerate, so anchor the ratio to the first non-zero
+		// rate actually produced by the schedule instead.
+		s.baseRate = rate
+		s.haveBaseRate = true
+	default:
+		floats.Scale(rate/s.baseRate, step)
+	}
+}
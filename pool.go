@@ -0,0 +1,83 @@
+package sgd
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// gradJob is a chunk of a minibatch dispatched to a gradPool worker: evaluate
+// Func and Grad at params over idxs, which is to be written back starting at
+// offset within the batch.
+type gradJob struct {
+	params []float64
+	idxs   []int
+	offset int
+}
+
+// gradResult is the reduction of a gradJob. fun holds the per-sample function
+// values for the chunk (to be written back at offset), and grad holds the sum
+// of the chunk's gradient rows.
+type gradResult struct {
+	offset int
+	fun    []float64
+	grad   []float64
+}
+
+// gradPool is a set of long-lived worker goroutines that evaluate a Problem's
+// Func and Grad on disjoint chunks of a minibatch concurrently. See the
+// concurrency contract documented on Problem.
+type gradPool struct {
+	problem Problem
+	dim     int
+
+	jobs    chan gradJob
+	results chan gradResult
+	done    chan struct{}
+}
+
+// newGradPool starts n worker goroutines evaluating problem and returns the
+// pool. The caller must call stop when finished to release the goroutines.
+func newGradPool(problem Problem, dim, n int) *gradPool {
+	p := &gradPool{
+		problem: problem,
+		dim:     dim,
+		jobs:    make(chan gradJob),
+		results: make(chan gradResult),
+		done:    make(chan struct{}, n),
+	}
+	for i := 0; i < n; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *gradPool) work() {
+	var fun []float64
+	var grad *mat.Dense
+	for job := range p.jobs {
+		n := len(job.idxs)
+		fun = resizeZero(fun, n)
+		grad = resizeMat(grad, n, p.dim)
+		p.problem.Func(fun, job.params, job.idxs)
+		p.problem.Grad(grad, job.params, job.idxs)
+
+		// Copy out of the scratch buffers before sending, since fun and grad
+		// are reused by this worker on the next job.
+		funCopy := make([]float64, n)
+		copy(funCopy, fun)
+		gradSum := make([]float64, p.dim)
+		for i := 0; i < n; i++ {
+			floats.Add(gradSum, grad.RawRowView(i))
+		}
+		p.results <- gradResult{offset: job.offset, fun: funCopy, grad: gradSum}
+	}
+	p.done <- struct{}{}
+}
+
+// stop closes the job queue and waits for all workers to drain and exit.
+func (p *gradPool) stop() {
+	close(p.jobs)
+	for i := 0; i < cap(p.done); i++ {
+		<-p.done
+	}
+}
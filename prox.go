@@ -0,0 +1,153 @@
+package sgd
+
+import "math"
+
+// Prox is a proximal operator for a (possibly non-smooth) regularizer g.
+// Apply writes the proximal map of stepSize*g evaluated at in into out:
+//  out = argmin_z  g(z) + 1/(2*stepSize) ||z - in||^2
+// out and in may overlap.
+type Prox interface {
+	Apply(out, in []float64, stepSize float64)
+}
+
+var (
+	_ Prox = L1{}
+	_ Prox = L2Squared{}
+	_ Prox = ElasticNet{}
+)
+
+// L1 is the proximal operator for the L1 penalty Lambda*||θ||_1. Apply
+// performs elementwise soft-thresholding:
+//  out[i] = sign(in[i]) * max(|in[i]| - stepSize*Lambda, 0)
+type L1 struct {
+	Lambda float64
+}
+
+func (p L1) Apply(out, in []float64, stepSize float64) {
+	thresh := stepSize * p.Lambda
+	for i, v := range in {
+		switch {
+		case v > thresh:
+			out[i] = v - thresh
+		case v < -thresh:
+			out[i] = v + thresh
+		default:
+			out[i] = 0
+		}
+	}
+}
+
+// L2Squared is the proximal operator for the squared L2 penalty
+// Lambda*||θ||_2^2. Apply performs elementwise shrinkage:
+//  out[i] = in[i] / (1 + 2*stepSize*Lambda)
+type L2Squared struct {
+	Lambda float64
+}
+
+func (p L2Squared) Apply(out, in []float64, stepSize float64) {
+	scale := 1 / (1 + 2*stepSize*p.Lambda)
+	for i, v := range in {
+		out[i] = scale * v
+	}
+}
+
+// ElasticNet is the proximal operator for the elastic net penalty
+// L1.Lambda*||θ||_1 + L2.Lambda*||θ||_2^2. Apply soft-thresholds and then
+// shrinks, the closed-form proximal map of the combined penalty.
+type ElasticNet struct {
+	L1 L1
+	L2 L2Squared
+}
+
+func (p ElasticNet) Apply(out, in []float64, stepSize float64) {
+	thresh := stepSize * p.L1.Lambda
+	scale := 1 / (1 + 2*stepSize*p.L2.Lambda)
+	for i, v := range in {
+		var t float64
+		switch {
+		case v > thresh:
+			t = v - thresh
+		case v < -thresh:
+			t = v + thresh
+		}
+		out[i] = scale * t
+	}
+}
+
+// FISTA implements the accelerated proximal gradient method of Beck &
+// Teboulle (2009) for composite objectives f(θ) + g(θ), where f is smooth
+// (its gradient is supplied by Problem.Grad) and g is a possibly non-smooth
+// regularizer with proximal operator Prox. FISTA implements ProxStepper,
+// since its update depends on the current parameters, and ProxEvaluator,
+// since the gradient of f must be evaluated at its momentum point y rather
+// than at the current parameters.
+type FISTA struct {
+	// Size sets the step size η used in the proximal gradient step. If Size
+	// is 0, a default value of 0.01 is used.
+	Size float64
+	// Prox is the proximal operator for the non-smooth term g. Prox must be
+	// set before calling StepAt.
+	Prox Prox
+
+	y         []float64 // momentum point
+	thetaNext []float64
+	thetaPrev []float64
+	z         []float64 // scratch: y - Size*grad
+	t         float64
+	first     bool
+}
+
+var _ ProxEvaluator = &FISTA{}
+
+func (f *FISTA) Init(dim int) {
+	if f.Size == 0 {
+		f.Size = 0.01
+	}
+	f.y = resizeZero(f.y, dim)
+	f.thetaNext = resizeZero(f.thetaNext, dim)
+	f.thetaPrev = resizeZero(f.thetaPrev, dim)
+	f.z = resizeZero(f.z, dim)
+	f.t = 1
+	f.first = true
+}
+
+// Step panics: FISTA's update depends on the current parameters, so it must
+// be driven through StepAt. SGD detects this via the ProxStepper interface
+// and calls StepAt automatically.
+func (f *FISTA) Step(step, grad []float64) {
+	panic("sgd: FISTA requires the current parameters; call StepAt")
+}
+
+// EvalPoint returns FISTA's momentum point y, satisfying ProxEvaluator, so
+// SGD evaluates Problem.Grad there instead of at params. On the first call,
+// y has no history yet, so it is initialized to params.
+func (f *FISTA) EvalPoint(params []float64) []float64 {
+	if f.first {
+		copy(f.y, params)
+		copy(f.thetaPrev, params)
+		f.t = 1
+		f.first = false
+	}
+	return f.y
+}
+
+func (f *FISTA) StepAt(step, params, grad []float64) {
+	if f.Prox == nil {
+		panic("sgd: FISTA.Prox is nil")
+	}
+
+	// grad is ∇f(y), evaluated by SGD at the point returned from EvalPoint.
+	for i, g := range grad {
+		f.z[i] = f.y[i] - f.Size*g
+	}
+	f.Prox.Apply(f.thetaNext, f.z, f.Size)
+
+	tNext := (1 + math.Sqrt(1+4*f.t*f.t)) / 2
+	coef := (f.t - 1) / tNext
+	for i := range f.thetaNext {
+		step[i] = f.thetaNext[i] - params[i]
+		f.y[i] = f.thetaNext[i] + coef*(f.thetaNext[i]-f.thetaPrev[i])
+	}
+	copy(f.thetaPrev, f.thetaNext)
+	f.t = tNext
+}
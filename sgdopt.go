@@ -16,20 +16,72 @@ type Settings struct {
 	// If Iterations is 0, then an unlimited iteration count is used by default.
 	Iterations int
 
+	// Epochs is an alternative stop criterion to Iterations, expressed as a
+	// number of complete passes over the dataset. If Epochs is non-zero and
+	// the Batcher implements BatchSizer, it is translated into an equivalent
+	// Iterations count of Epochs*ceil(Problem.Size/batchSize); otherwise it is
+	// ignored.
+	Epochs int
+
 	// StepTolerance sets the stopping tolerance on the step size. If StepTolerance
 	// is zero then it is defaulted to a value of 1e-8.
 	StepTolerance float64
+
+	// Concurrency sets the number of goroutines used to evaluate Problem.Func
+	// and Problem.Grad on a minibatch. If Concurrency is 0 or 1, the batch is
+	// evaluated serially on the calling goroutine. If Concurrency is greater
+	// than 1, the batch indices are partitioned into chunks of GrainSize and
+	// evaluated concurrently; see the concurrency contract documented on
+	// Problem.
+	Concurrency int
+	// GrainSize sets the number of indices evaluated per dispatched chunk when
+	// Concurrency is greater than 1. If GrainSize is 0, it defaults to
+	// enough indices to give each goroutine roughly one chunk per minibatch.
+	GrainSize int
+
+	// FunctionTolerance sets the stopping tolerance on the change in the
+	// smoothed function value (see FunctionAverageWindow). If FunctionTolerance
+	// is 0, this stop criterion is disabled.
+	FunctionTolerance float64
+	// FunctionAverageWindow sets the number of trailing minibatch loss values
+	// averaged together to smooth the function value used by
+	// FunctionTolerance. If FunctionAverageWindow is 0, a default value of 20
+	// is used.
+	FunctionAverageWindow int
+	// RecordHistory sets whether the smoothed function value is recorded at
+	// each iteration into Result.FHistory.
+	RecordHistory bool
+	// Recorder, if non-nil, is called once per iteration with the current
+	// state of the optimization, for external logging (CSV, plotting, etc.)
+	// without modifying the core loop.
+	Recorder Recorder
 }
 
 func defaultSettings(set *Settings) {
 	if set.StepTolerance == 0 {
 		set.StepTolerance = 1e-8
 	}
+	if set.FunctionAverageWindow == 0 {
+		set.FunctionAverageWindow = 20
+	}
+}
+
+// Recorder is an optional hook for external logging of the optimization
+// trajectory. Record is called once per iteration with the parameters and
+// gradient used to compute step, and the smoothed loss for that iteration.
+type Recorder interface {
+	Record(iter int, params, grad, step []float64, loss float64)
 }
 
 type Result struct {
 	X      []float64
 	Status optimize.Status
+	// F is the last smoothed function value computed over
+	// Settings.FunctionAverageWindow trailing minibatches.
+	F float64
+	// FHistory holds the smoothed function value at each iteration, if
+	// Settings.RecordHistory was set.
+	FHistory []float64
 }
 
 func SGD(problem Problem, batcher Batcher, stepper Stepper, settings *Settings) (*Result, error) {
@@ -47,11 +99,36 @@ func SGD(problem Problem, batcher Batcher, stepper Stepper, settings *Settings)
 	}
 	batcher.Init(size)
 	stepper.Init(dim)
+	proxStepper, _ := stepper.(ProxStepper)
+	proxEvaluator, _ := stepper.(ProxEvaluator)
+	epochStepper, _ := stepper.(EpochAware)
+	iterStepper, _ := stepper.(IterAware)
+	epochBatcher, _ := batcher.(EpochBatcher)
 	defaultSettings(&set)
+	if set.Epochs != 0 {
+		if bs, ok := batcher.(BatchSizer); ok {
+			itersPerEpoch := (size + bs.BatchSize() - 1) / bs.BatchSize()
+			set.Iterations = set.Epochs * itersPerEpoch
+		}
+	}
 	status := optimize.NotTerminated
+	lastEpoch := -1
 	var dstFun []float64
 	var avgGrad []float64
 	var dstGrads *mat.Dense
+
+	lossWindow := make([]float64, set.FunctionAverageWindow)
+	var lossPos, lossCount int
+	var lastF, prevSmoothedF float64
+	var haveSmoothedF bool
+	var fHistory []float64
+
+	var pool *gradPool
+	if set.Concurrency > 1 {
+		pool = newGradPool(problem, dim, set.Concurrency)
+		defer pool.stop()
+	}
+
 	parameters := make([]float64, dim)
 	step := make([]float64, dim)
 	for iter := 0; ; iter++ {
@@ -59,27 +136,93 @@ func SGD(problem Problem, batcher Batcher, stepper Stepper, settings *Settings)
 			status = optimize.IterationLimit
 			break
 		}
+		if iterStepper != nil {
+			iterStepper.NotifyIter(iter)
+		}
 		batch := batcher.Batch()
+		if epochBatcher != nil && epochStepper != nil {
+			if epoch := epochBatcher.Epoch(); epoch != lastEpoch {
+				epochStepper.NotifyEpoch(epoch)
+				lastEpoch = epoch
+			}
+		}
 
-		// Evaluate the function and gradient.
-		// TODO(btracey): Will need the function for certain convergence measures
-		// and recording things.
+		// Evaluate the function and gradient. This is ordinarily done at the
+		// current parameters, but a ProxEvaluator (e.g. an accelerated method
+		// like FISTA) evaluates at its own internal point instead.
 		nData := len(batch)
 		dstFun = resizeZero(dstFun, nData)
-		dstGrads = resizeMat(dstGrads, nData, dim)
+		avgGrad = resizeZero(avgGrad, dim)
 
-		problem.Func(dstFun, parameters, batch)
-		problem.Grad(dstGrads, parameters, batch)
+		evalPoint := parameters
+		if proxEvaluator != nil {
+			evalPoint = proxEvaluator.EvalPoint(parameters)
+		}
 
-		// Given the gradient, update the step.
-		avgGrad = resizeZero(avgGrad, dim)
-		for i := 0; i < nData; i++ {
-			floats.Add(avgGrad, dstGrads.RawRowView(i))
+		if pool != nil {
+			grain := set.GrainSize
+			if grain == 0 {
+				grain = (nData + set.Concurrency - 1) / set.Concurrency
+			}
+			nChunks := (nData + grain - 1) / grain
+
+			// Dispatch from a separate goroutine so sending jobs can't block
+			// on workers that are themselves blocked sending results back:
+			// with more chunks than workers, every worker would otherwise
+			// fill up on its first job and deadlock against this goroutine
+			// handing out the next one.
+			go func() {
+				for offset := 0; offset < nData; offset += grain {
+					end := offset + grain
+					if end > nData {
+						end = nData
+					}
+					pool.jobs <- gradJob{params: evalPoint, idxs: batch[offset:end], offset: offset}
+				}
+			}()
+			for i := 0; i < nChunks; i++ {
+				res := <-pool.results
+				copy(dstFun[res.offset:res.offset+len(res.fun)], res.fun)
+				floats.Add(avgGrad, res.grad)
+			}
+		} else {
+			dstGrads = resizeMat(dstGrads, nData, dim)
+			problem.Func(dstFun, evalPoint, batch)
+			problem.Grad(dstGrads, evalPoint, batch)
+			for i := 0; i < nData; i++ {
+				floats.Add(avgGrad, dstGrads.RawRowView(i))
+			}
+		}
+
+		// Track a smoothed loss for the function-value convergence criterion.
+		lossWindow[lossPos] = floats.Sum(dstFun) / float64(nData)
+		lossPos = (lossPos + 1) % len(lossWindow)
+		if lossCount < len(lossWindow) {
+			lossCount++
+		}
+		var smoothedF float64
+		for i := 0; i < lossCount; i++ {
+			smoothedF += lossWindow[i]
 		}
+		smoothedF /= float64(lossCount)
+		lastF = smoothedF
+		if set.RecordHistory {
+			fHistory = append(fHistory, smoothedF)
+		}
+
+		// Given the gradient, update the step.
 		floats.Scale(1/float64(nData), avgGrad)
-		stepper.Step(step, avgGrad)
+		if proxStepper != nil {
+			proxStepper.StepAt(step, parameters, avgGrad)
+		} else {
+			stepper.Step(step, avgGrad)
+		}
 		//fmt.Println(step)
 
+		if set.Recorder != nil {
+			set.Recorder.Record(iter, parameters, avgGrad, step, smoothedF)
+		}
+
 		stepNorm := floats.Norm(step, 2)
 		//fmt.Println(parameters)
 		//fmt.Println(stepNorm)
@@ -91,16 +234,31 @@ func SGD(problem Problem, batcher Batcher, stepper Stepper, settings *Settings)
 			status = optimize.StepConvergence
 			break
 		}
+		if set.FunctionTolerance > 0 && lossCount == len(lossWindow) {
+			if haveSmoothedF && math.Abs(smoothedF-prevSmoothedF) < set.FunctionTolerance {
+				status = optimize.FunctionConvergence
+				break
+			}
+			prevSmoothedF = smoothedF
+			haveSmoothedF = true
+		}
 		floats.Add(parameters, step)
 		//fmt.Println("parameters = ", parameters)
 	}
 	return &Result{
-		X:      parameters,
-		Status: status,
+		X:        parameters,
+		Status:   status,
+		F:        lastF,
+		FHistory: fHistory,
 	}, nil
 }
 
 // Problem is a function for running stochastic gradient descent.
+//
+// If Settings.Concurrency is greater than 1, Func and Grad must be safe to
+// call concurrently from multiple goroutines, provided each call is given a
+// disjoint slice of idxs and a disjoint destination (dst). param is read-only
+// and shared across all concurrent calls for a given minibatch.
 type Problem struct {
 	// Dim is the dimension of the parameters of the problem.
 	Dim int
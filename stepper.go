@@ -18,14 +18,74 @@ type Stepper interface {
 	Step(step, grad []float64)
 }
 
+// ProxStepper is an optional interface a Stepper may additionally implement
+// when the step it computes depends on the current parameters, such as
+// proximal-gradient methods for composite objectives. If a Stepper implements
+// ProxStepper, SGD calls StepAt instead of Step.
+type ProxStepper interface {
+	Stepper
+	// StepAt computes the next update to the gradient descent given the
+	// current parameters params and gradient grad, storing the result in
+	// step. See Stepper for the calling convention.
+	StepAt(step, params, grad []float64)
+}
+
+// ProxEvaluator is an optional interface a ProxStepper may additionally
+// implement when its update requires the smooth gradient evaluated at a
+// point other than the current parameters, such as an accelerated method's
+// momentum point. If implemented, SGD evaluates Problem.Func/Problem.Grad at
+// EvalPoint(params) instead of at the current parameters, and passes the
+// resulting gradient (evaluated there) to StepAt; the params argument to
+// StepAt itself remains the true current parameters.
+type ProxEvaluator interface {
+	ProxStepper
+	// EvalPoint returns the point at which Problem.Func/Problem.Grad should
+	// be evaluated for the upcoming call to StepAt, given the true current
+	// parameters. The returned slice must not be modified by the caller.
+	EvalPoint(params []float64) []float64
+}
+
+// EpochAware is an optional interface a Stepper may implement to be notified
+// when the Batcher begins a new epoch, for example to drive a step-decay
+// learning rate schedule keyed to epoch count. SGD calls NotifyEpoch
+// automatically when the Batcher implements EpochBatcher.
+type EpochAware interface {
+	NotifyEpoch(epoch int)
+}
+
+// IterAware is an optional interface a Stepper may implement to be notified
+// of the current iteration number before each call to Step or StepAt, for
+// example to drive a learning-rate schedule. SGD calls NotifyIter once per
+// iteration.
+type IterAware interface {
+	NotifyIter(iter int)
+}
+
+// RateSetter is an optional interface implemented by steppers that expose an
+// adjustable learning rate (Adam, Adagrad, RMSProp). Scheduled calls SetRate
+// directly when Base implements it, rather than rescaling the returned step.
+type RateSetter interface {
+	SetRate(rate float64)
+}
+
 var (
 	_ Stepper = &Adadelta{}
 	_ Stepper = &Adagrad{}
 	_ Stepper = &Adam{}
+	_ Stepper = &Adamax{}
+	_ Stepper = &AMSGrad{}
 	_ Stepper = &Anneal{}
+	_ Stepper = &FISTA{}
+	_ Stepper = &LBFGS{}
 	_ Stepper = &Momentum{}
+	_ Stepper = &Nadam{}
 	_ Stepper = &Nesterov{}
 	_ Stepper = &RMSProp{}
+	_ Stepper = &Scheduled{}
+
+	_ RateSetter = &Adagrad{}
+	_ RateSetter = &Adam{}
+	_ RateSetter = &RMSProp{}
 )
 
 // Adadelta is a stepper with a per-parameter step size that is adjusted
@@ -97,6 +157,11 @@ func (a *Adagrad) Step(step, grad []float64) {
 	}
 }
 
+// SetRate sets the learning rate η, satisfying RateSetter.
+func (a *Adagrad) SetRate(rate float64) {
+	a.Size = rate
+}
+
 // Adam is a stepper with a per-parameter step size that uses both a decaying
 // average of past gradients and a momentum term.
 //  m_t = γ_1 * m_{t-1} + (1-γ_1) * g_t
@@ -153,6 +218,116 @@ func (a *Adam) Step(step, grad []float64) {
 	}
 }
 
+// SetRate sets the learning rate η, satisfying RateSetter.
+func (a *Adam) SetRate(rate float64) {
+	a.Size = rate
+}
+
+// Adamax is a variant of Adam based on the infinity norm.
+//  m_t = γ_1 * m_{t-1} + (1-γ_1) * g_t
+//  u_t = max(γ_2 * u_{t-1}, |g_t|)
+//  step = - η/(1-γ_1^t) ⊙ m_t/(u_t+ϵ)
+// For more information see https://arxiv.org/pdf/1412.6980.pdf .
+type Adamax struct {
+	// Size sets the value of the parameter η. If Size is 0, a default value of
+	// 0.002 is used.
+	Size float64
+	// MeanMomen sets the momentum term for the mean gradient γ_1. If MeanMomen
+	// is 0, a default value of 0.9 is used.
+	MeanMomen float64
+	// VarMomen sets the momentum term for the infinity norm γ_2. If VarMomen
+	// is 0, a default value of 0.999 is used.
+	VarMomen float64
+	// Smooth sets the value of the smoothing parameter ϵ. If Smooth is 0, a
+	// default value of 1e-8 is used.
+	Smooth float64
+
+	time float64
+	m    []float64
+	u    []float64
+}
+
+func (a *Adamax) Init(dim int) {
+	if a.Size == 0 {
+		a.Size = 0.002
+	}
+	if a.MeanMomen == 0 {
+		a.MeanMomen = 0.9
+	}
+	if a.VarMomen == 0 {
+		a.VarMomen = 0.999
+	}
+	if a.Smooth == 0 {
+		a.Smooth = 1e-8
+	}
+	a.time = 0
+	a.m = resizeZero(a.m, dim)
+	a.u = resizeZero(a.u, dim)
+}
+
+func (a *Adamax) Step(step, grad []float64) {
+	a.time++
+	for i, v := range grad {
+		a.m[i] = a.MeanMomen*a.m[i] + (1-a.MeanMomen)*v
+		a.u[i] = math.Max(a.VarMomen*a.u[i], math.Abs(v))
+		step[i] = -a.Size / (1 - math.Pow(a.MeanMomen, a.time)) * a.m[i] / (a.u[i] + a.Smooth)
+	}
+}
+
+// AMSGrad is a variant of Adam that maintains the maximum of the past
+// second-moment estimates rather than an exponential average, which fixes
+// convergence issues present in the original Adam analysis.
+//  m_t = γ_1 * m_{t-1} + (1-γ_1) * g_t
+//  ν_t = γ_2 * ν_{t-1} + (1-γ_2) * g_t ⊙ g_t
+//  ν̂_t = max(ν̂_{t-1}, ν_t)
+//  step = - η/(sqrt(ν̂_t)+ϵ) ⊙ m_t
+// For more information see https://arxiv.org/abs/1904.09237 .
+type AMSGrad struct {
+	// Size sets the value of the parameter η. If Size is 0, a default value of
+	// 0.001 is used.
+	Size float64
+	// MeanMomen sets the momentum term for the mean gradient γ_1. If MeanMomen
+	// is 0, a default value of 0.9 is used.
+	MeanMomen float64
+	// VarMomen sets the momentum term for the variance of the gradient γ_2.
+	// If VarMomen is 0, a default value of 0.999 is used.
+	VarMomen float64
+	// Smooth sets the value of the smoothing parameter ϵ. If Smooth is 0, a
+	// default value of 1e-8 is used.
+	Smooth float64
+
+	m     []float64
+	nu    []float64
+	nuHat []float64
+}
+
+func (a *AMSGrad) Init(dim int) {
+	if a.Size == 0 {
+		a.Size = 0.001
+	}
+	if a.MeanMomen == 0 {
+		a.MeanMomen = 0.9
+	}
+	if a.VarMomen == 0 {
+		a.VarMomen = 0.999
+	}
+	if a.Smooth == 0 {
+		a.Smooth = 1e-8
+	}
+	a.m = resizeZero(a.m, dim)
+	a.nu = resizeZero(a.nu, dim)
+	a.nuHat = resizeZero(a.nuHat, dim)
+}
+
+func (a *AMSGrad) Step(step, grad []float64) {
+	for i, v := range grad {
+		a.m[i] = a.MeanMomen*a.m[i] + (1-a.MeanMomen)*v
+		a.nu[i] = a.VarMomen*a.nu[i] + (1-a.VarMomen)*v*v
+		a.nuHat[i] = math.Max(a.nuHat[i], a.nu[i])
+		step[i] = -a.Size / (math.Sqrt(a.nuHat[i]) + a.Smooth) * a.m[i]
+	}
+}
+
 // Anneal is a stepper that has a step size which is annealed over time.
 // Anneal computes the step as
 //  η = a / (b + t)
@@ -189,6 +364,131 @@ func (a *Anneal) Step(step, grad []float64) {
 	a.time++
 }
 
+// LBFGS is a stepper implementing a stochastic/online variant of limited-memory
+// BFGS. It keeps a ring buffer of the last Memory (s, y) curvature pairs,
+// where s_k is the previous step taken and y_k is the corresponding change in
+// gradient, and combines them with the current gradient via the standard
+// two-loop recursion to approximate a Newton direction. Because the steps
+// taken by the caller are not known exactly (only the previous step returned
+// by Step), s_k is approximated as the step returned on the previous call
+// (θ_k - θ_{k-1} = prevStep, since the caller updates θ += step); pairs whose
+// curvature s_k^T y_k is not above lbfgsCurvatureEps are discarded to guard
+// against the curvature condition failing under gradient noise.
+type LBFGS struct {
+	// Memory sets the number of (s, y) pairs retained. If Memory is 0, a
+	// default value of 10 is used.
+	Memory int
+	// Size sets the initial step scaling used before any curvature pairs are
+	// available, and the overall scale of the returned step. If Size is 0, a
+	// default value of 1 is used.
+	Size float64
+
+	s     [][]float64
+	y     [][]float64
+	rho   []float64
+	alpha []float64
+	q     []float64
+
+	gPrev    []float64
+	prevStep []float64
+	hasPrev  bool
+
+	count int // number of valid pairs stored, up to Memory.
+	next  int // ring buffer index the next pair will be written to.
+}
+
+func (l *LBFGS) Init(dim int) {
+	if l.Memory == 0 {
+		l.Memory = 10
+	}
+	if l.Size == 0 {
+		l.Size = 1
+	}
+	l.s = make([][]float64, l.Memory)
+	l.y = make([][]float64, l.Memory)
+	l.rho = make([]float64, l.Memory)
+	l.alpha = make([]float64, l.Memory)
+	l.q = resizeZero(l.q, dim)
+	l.gPrev = resizeZero(l.gPrev, dim)
+	l.prevStep = resizeZero(l.prevStep, dim)
+	l.hasPrev = false
+	l.count = 0
+	l.next = 0
+}
+
+// lbfgsCurvatureEps is the curvature s^T y a pair must exceed to be kept.
+// Pairs below this threshold are skipped rather than corrupting the Hessian
+// approximation, which matters when grad is a noisy stochastic estimate.
+const lbfgsCurvatureEps = 1e-10
+
+func (l *LBFGS) Step(step, grad []float64) {
+	dim := len(grad)
+	if l.hasPrev {
+		var sy float64
+		for i := range grad {
+			sy += l.prevStep[i] * (grad[i] - l.gPrev[i])
+		}
+		if sy > lbfgsCurvatureEps {
+			idx := l.next
+			if l.s[idx] == nil {
+				l.s[idx] = make([]float64, dim)
+				l.y[idx] = make([]float64, dim)
+			}
+			sk := l.s[idx]
+			yk := l.y[idx]
+			for i := range sk {
+				sk[i] = l.prevStep[i]
+				yk[i] = grad[i] - l.gPrev[i]
+			}
+			l.rho[idx] = 1 / sy
+			l.next = (l.next + 1) % l.Memory
+			if l.count < l.Memory {
+				l.count++
+			}
+		}
+	}
+
+	copy(l.q, grad)
+	if l.count == 0 {
+		copy(step, l.q)
+		floats.Scale(-l.Size, step)
+	} else {
+		idx := (l.next - 1 + l.Memory) % l.Memory
+		for k := 0; k < l.count; k++ {
+			si, yi, rhoi := l.s[idx], l.y[idx], l.rho[idx]
+			a := rhoi * floats.Dot(si, l.q)
+			l.alpha[k] = a
+			for j := range l.q {
+				l.q[j] -= a * yi[j]
+			}
+			idx = (idx - 1 + l.Memory) % l.Memory
+		}
+
+		newest := (l.next - 1 + l.Memory) % l.Memory
+		sNewest, yNewest := l.s[newest], l.y[newest]
+		gamma := floats.Dot(sNewest, yNewest) / floats.Dot(yNewest, yNewest)
+		floats.Scale(gamma, l.q)
+
+		idx = (l.next - l.count + l.Memory) % l.Memory // oldest
+		for k := l.count - 1; k >= 0; k-- {
+			si, yi, rhoi := l.s[idx], l.y[idx], l.rho[idx]
+			beta := rhoi * floats.Dot(yi, l.q)
+			a := l.alpha[k]
+			for j := range l.q {
+				l.q[j] += (a - beta) * si[j]
+			}
+			idx = (idx + 1) % l.Memory
+		}
+
+		copy(step, l.q)
+		floats.Scale(-l.Size, step)
+	}
+
+	copy(l.gPrev, grad)
+	copy(l.prevStep, step)
+	l.hasPrev = true
+}
+
 // Momentum is a stepper that implements a momentum-based step direction.
 // Specifically, Momentum sets
 //  η = a / (b + t)
@@ -234,6 +534,62 @@ func (m *Momentum) Step(step, grad []float64) {
 	m.time++
 }
 
+// Nadam is a stepper that combines Adam with Nesterov-accelerated momentum.
+//  m_t = γ_1 * m_{t-1} + (1-γ_1) * g_t
+//  ν_t = γ_2 * ν_{t-1} + (1-γ_2) * g_t ⊙ g_t
+//  m̂_t = m_t/(1-γ_1^t)
+//  ν̂_t = ν_t/(1-γ_2^t)
+//  step = - η/(sqrt(ν̂_t)+ϵ) ⊙ (γ_1*m̂_t + (1-γ_1)*g_t/(1-γ_1^t))
+// For more information see http://cs229.stanford.edu/proj2015/054_report.pdf .
+type Nadam struct {
+	// Size sets the value of the parameter η. If Size is 0, a default value of
+	// 0.002 is used.
+	Size float64
+	// MeanMomen sets the momentum term for the mean gradient γ_1. If MeanMomen
+	// is 0, a default value of 0.9 is used.
+	MeanMomen float64
+	// VarMomen sets the momentum term for the variance of the gradient γ_2.
+	// If VarMomen is 0, a default value of 0.999 is used.
+	VarMomen float64
+	// Smooth sets the value of the smoothing parameter ϵ. If Smooth is 0, a
+	// default value of 1e-8 is used.
+	Smooth float64
+
+	time float64
+	m    []float64
+	nu   []float64
+}
+
+func (n *Nadam) Init(dim int) {
+	if n.Size == 0 {
+		n.Size = 0.002
+	}
+	if n.MeanMomen == 0 {
+		n.MeanMomen = 0.9
+	}
+	if n.VarMomen == 0 {
+		n.VarMomen = 0.999
+	}
+	if n.Smooth == 0 {
+		n.Smooth = 1e-8
+	}
+	n.time = 0
+	n.m = resizeZero(n.m, dim)
+	n.nu = resizeZero(n.nu, dim)
+}
+
+func (n *Nadam) Step(step, grad []float64) {
+	n.time++
+	for i, v := range grad {
+		n.m[i] = n.MeanMomen*n.m[i] + (1-n.MeanMomen)*v
+		n.nu[i] = n.VarMomen*n.nu[i] + (1-n.VarMomen)*v*v
+		mhat := n.m[i] / (1 - math.Pow(n.MeanMomen, n.time))
+		nuhat := n.nu[i] / (1 - math.Pow(n.VarMomen, n.time))
+		nesterov := n.MeanMomen*mhat + (1-n.MeanMomen)*v/(1-math.Pow(n.MeanMomen, n.time))
+		step[i] = -n.Size / (math.Sqrt(nuhat) + n.Smooth) * nesterov
+	}
+}
+
 // Nesterov implements Nesterov's Accelerated Gradient Descent.
 // Nesterov sets
 //  μ = 1 - 3/(t+5)
@@ -309,3 +665,8 @@ func (r *RMSProp) Step(step, grad []float64) {
 	}
 	copy(step, r.s)
 }
+
+// SetRate sets the learning rate η, satisfying RateSetter.
+func (r *RMSProp) SetRate(rate float64) {
+	r.Rate = rate
+}